@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Event is a single structured entry pushed to /ws/events subscribers: a
+// proxied request (redirect, CONNECT tunnel, or SOCKS5 tunnel) or a mapping
+// reload.
+type Event struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	ShortHost string    `json:"short_host,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	FinalURL  string    `json:"final_url,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	Status    int       `json:"status,omitempty"`
+	LatencyMs int64     `json:"latency_ms,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// eventBus fans out published events to any number of subscriber channels.
+// Publish never blocks on a slow subscriber: events are dropped for that
+// subscriber rather than stalling the proxy hot path.
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subscribers: make(map[chan Event]struct{})}
+}
+
+func (b *eventBus) subscribe() chan Event {
+	ch := make(chan Event, 32)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *eventBus) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+	b.mu.Unlock()
+}
+
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// Subscriber isn't keeping up; drop rather than block publishers.
+		}
+	}
+}
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// CheckOrigin accepts every origin. This is a deliberate choice, not an
+	// oversight: /check and /metrics are equally unauthenticated, so gating
+	// the origin here wouldn't change who can reach this data, only whether
+	// a browser enforces it. If an auth layer is ever added in front of this
+	// service, this should be revisited alongside those other endpoints
+	// rather than fixed in isolation, since /ws/events streams more
+	// sensitive data (client IPs, real target URLs) per event than the
+	// snapshot endpoints do.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// handleEvents upgrades the connection to a WebSocket and streams JSON
+// events (one per line) until the client disconnects.
+func (ps *ProxyServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[%s] WebSocket upgrade failed: %v", time.Now().Format(time.RFC3339), err)
+		return
+	}
+	defer conn.Close()
+
+	sub := ps.events.subscribe()
+	defer ps.events.unsubscribe(sub)
+
+	log.Printf("[%s] Event stream subscriber connected from %s", time.Now().Format(time.RFC3339), r.RemoteAddr)
+
+	for event := range sub {
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err := conn.WriteJSON(event); err != nil {
+			log.Printf("[%s] Event stream write failed, dropping subscriber: %v", time.Now().Format(time.RFC3339), err)
+			return
+		}
+	}
+}
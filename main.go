@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -23,8 +24,12 @@ var startTime time.Time
 
 // Configuration from environment variables
 type Config struct {
-	Port       string
-	ConfigFile string
+	Port             string
+	ConfigFile       string
+	MappingSourceURL string
+	SyncInterval     time.Duration
+	TLSCertFile      string
+	TLSKeyFile       string
 }
 
 // APIResponse represents the response from the remote API
@@ -50,12 +55,19 @@ type Record struct {
 
 // ProxyServer holds the state of the proxy service
 type ProxyServer struct {
-	config       Config
-	shortLinkMap map[string]string
-	mapMutex     sync.RWMutex
-	lastSyncTime time.Time
-	requestCount uint64
-	countMutex   sync.RWMutex
+	config        Config
+	matchers      []*Matcher
+	mapMutex      sync.RWMutex
+	lastSyncTime  time.Time
+	events        *eventBus
+	metrics       *Metrics
+	httpsListener *ChannelListener
+
+	mappingSource    MappingSource
+	syncETag         string
+	syncLastModified string
+	lastSyncStatus   string
+	lastSyncError    string
 }
 
 func loadConfig() Config {
@@ -69,68 +81,148 @@ func loadConfig() Config {
 		configFile = "config.json"
 	}
 
+	mappingSourceURL := os.Getenv("MAPPING_SOURCE")
+	if mappingSourceURL == "" {
+		mappingSourceURL = defaultMappingSourceURL(configFile)
+	}
+
+	syncInterval := 60 * time.Second
+	if raw := os.Getenv("SYNC_INTERVAL"); raw != "" {
+		if parsed, err := time.ParseDuration(raw); err == nil {
+			syncInterval = parsed
+		} else {
+			log.Printf("Invalid SYNC_INTERVAL %q, using default of %s: %v", raw, syncInterval, err)
+		}
+	}
+
 	return Config{
-		Port:       port,
-		ConfigFile: configFile,
+		Port:             port,
+		ConfigFile:       configFile,
+		MappingSourceURL: mappingSourceURL,
+		SyncInterval:     syncInterval,
+		TLSCertFile:      os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:       os.Getenv("TLS_KEY_FILE"),
 	}
 }
 
 func NewProxyServer(config Config) *ProxyServer {
+	source, err := newMappingSource(config.MappingSourceURL)
+	if err != nil {
+		log.Printf("Invalid MAPPING_SOURCE %q, falling back to file://%s: %v", config.MappingSourceURL, config.ConfigFile, err)
+		source = &fileMappingSource{path: config.ConfigFile}
+	}
+
 	return &ProxyServer{
-		config:       config,
-		shortLinkMap: make(map[string]string),
+		config:        config,
+		events:        newEventBus(),
+		metrics:       newMetrics(),
+		mappingSource: source,
 	}
 }
 
-// loadMappingsFromFile loads mappings from local config file
-func (ps *ProxyServer) loadMappingsFromFile() error {
-	log.Printf("[%s] Loading mappings from %s...", time.Now().Format(time.RFC3339), ps.config.ConfigFile)
+// syncMappings polls ps.mappingSource for the current set of records,
+// sending conditional request hints (ETag/Last-Modified) so an unchanged
+// source is a cheap no-op, and atomically swaps the compiled matchers when
+// the data actually changed. lastSyncTime/lastSyncStatus/lastSyncError are
+// updated on every call (including no-ops and failures) for /check to
+// report.
+func (ps *ProxyServer) syncMappings(ctx context.Context) error {
+	log.Printf("[%s] Syncing mappings from %s...", time.Now().Format(time.RFC3339), ps.config.MappingSourceURL)
 
-	body, err := os.ReadFile(ps.config.ConfigFile)
+	ps.mapMutex.RLock()
+	prevETag := ps.syncETag
+	prevLastModified := ps.syncLastModified
+	ps.mapMutex.RUnlock()
+
+	apiResp, etag, lastModified, notModified, err := ps.mappingSource.Fetch(ctx, prevETag, prevLastModified)
 	if err != nil {
-		log.Printf("[%s] Failed to read config file: %v", time.Now().Format(time.RFC3339), err)
+		log.Printf("[%s] Mapping sync failed: %v", time.Now().Format(time.RFC3339), err)
+		ps.recordSyncResult(err)
 		return err
 	}
 
-	var apiResp APIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		log.Printf("[%s] Failed to parse JSON: %v", time.Now().Format(time.RFC3339), err)
-		return err
+	if notModified {
+		log.Printf("[%s] Mapping source unchanged, skipping swap.", time.Now().Format(time.RFC3339))
+		ps.recordSyncResult(nil)
+		return nil
 	}
 
 	if !apiResp.Success {
-		log.Printf("[%s] Config file indicates error: %s", time.Now().Format(time.RFC3339), apiResp.Message)
-		return fmt.Errorf("config error: %s", apiResp.Message)
+		err := fmt.Errorf("mapping source error: %s", apiResp.Message)
+		ps.recordSyncResult(err)
+		return err
 	}
 
-	newMap := make(map[string]string)
+	newMatchers := make([]*Matcher, 0, len(apiResp.Data))
 	for _, record := range apiResp.Data {
-		if record.ShortURL != "" && record.LongURL != "" {
-			// Construct full URL with protocol
-			fullURL := record.Protocol + "://" + record.LongURL
-			newMap[strings.ToLower(record.ShortURL)] = fullURL
-		} else {
+		if record.ShortURL == "" || record.LongURL == "" {
 			log.Printf("[%s] Invalid record received: %+v", time.Now().Format(time.RFC3339), record)
+			continue
 		}
+		// Construct full URL with protocol
+		fullURL := record.Protocol + "://" + record.LongURL
+		matcher, err := newMatcher(record.ShortURL, fullURL, record.AllowURIConcat)
+		if err != nil {
+			log.Printf("[%s] Invalid short URL pattern %q: %v", time.Now().Format(time.RFC3339), record.ShortURL, err)
+			continue
+		}
+		newMatchers = append(newMatchers, matcher)
 	}
 
-	if len(newMap) == 0 {
-		return fmt.Errorf("no valid records found in config file")
+	if len(newMatchers) == 0 {
+		err := fmt.Errorf("no valid records found from mapping source")
+		ps.recordSyncResult(err)
+		return err
 	}
 
 	ps.mapMutex.Lock()
-	ps.shortLinkMap = newMap
-	ps.lastSyncTime = time.Now()
+	ps.matchers = newMatchers
+	ps.syncETag = etag
+	ps.syncLastModified = lastModified
 	ps.mapMutex.Unlock()
-	
-	log.Printf("[%s] Mappings loaded successfully. Total %d records.", time.Now().Format(time.RFC3339), len(newMap))
+
+	ps.recordSyncResult(nil)
+	log.Printf("[%s] Mappings synced successfully. Total %d records.", time.Now().Format(time.RFC3339), len(newMatchers))
+	ps.events.publish(Event{
+		Type:      "reload",
+		Timestamp: time.Now(),
+		Status:    http.StatusOK,
+		Message:   fmt.Sprintf("synced %d mappings", len(newMatchers)),
+	})
 	return nil
 }
 
-// reloadMappings reloads mappings from config file (triggered by signal)
+// recordSyncResult stores the outcome of the most recent sync attempt for
+// /check to report, independent of whether the map actually changed.
+func (ps *ProxyServer) recordSyncResult(syncErr error) {
+	ps.mapMutex.Lock()
+	ps.lastSyncTime = time.Now()
+	if syncErr != nil {
+		ps.lastSyncStatus = "error"
+		ps.lastSyncError = syncErr.Error()
+	} else {
+		ps.lastSyncStatus = "ok"
+		ps.lastSyncError = ""
+	}
+	ps.mapMutex.Unlock()
+
+	ps.metrics.IncReload(syncErr == nil)
+
+	if syncErr != nil {
+		ps.events.publish(Event{
+			Type:      "reload",
+			Timestamp: time.Now(),
+			Status:    http.StatusInternalServerError,
+			Message:   syncErr.Error(),
+		})
+	}
+}
+
+// reloadMappings forces an immediate sync regardless of SYNC_INTERVAL
+// (triggered by SIGUSR1).
 func (ps *ProxyServer) reloadMappings() {
-	log.Printf("[%s] Reloading mappings from config file...", time.Now().Format(time.RFC3339))
-	if err := ps.loadMappingsFromFile(); err != nil {
+	log.Printf("[%s] Reloading mappings (forced sync)...", time.Now().Format(time.RFC3339))
+	if err := ps.syncMappings(context.Background()); err != nil {
 		log.Printf("[%s] Failed to reload mappings: %v", time.Now().Format(time.RFC3339), err)
 	} else {
 		log.Printf("[%s] Mappings reloaded successfully", time.Now().Format(time.RFC3339))
@@ -140,14 +232,14 @@ func (ps *ProxyServer) reloadMappings() {
 // handleCheck provides health check and status information
 func (ps *ProxyServer) handleCheck(w http.ResponseWriter, r *http.Request) {
 	ps.mapMutex.RLock()
-	mapSize := len(ps.shortLinkMap)
+	mapSize := len(ps.matchers)
 	lastSync := ps.lastSyncTime
+	lastSyncStatus := ps.lastSyncStatus
+	lastSyncError := ps.lastSyncError
 	ps.mapMutex.RUnlock()
 
 	// Get request count
-	ps.countMutex.RLock()
-	requestCount := ps.requestCount
-	ps.countMutex.RUnlock()
+	requestCount := ps.metrics.RequestsTotal()
 
 	// Calculate uptime
 	uptime := time.Since(startTime)
@@ -163,28 +255,32 @@ func (ps *ProxyServer) handleCheck(w http.ResponseWriter, r *http.Request) {
 			"total": mapSize,
 			"last_load": lastSync.Format(time.RFC3339),
 			"last_load_ago": time.Since(lastSync).String(),
+			"last_sync_status": lastSyncStatus,
+			"last_sync_error": lastSyncError,
 		},
 		"config": map[string]interface{}{
 			"port": ps.config.Port,
 			"config_file": ps.config.ConfigFile,
+			"mapping_source": ps.config.MappingSourceURL,
+			"sync_interval": ps.config.SyncInterval.String(),
 		},
 		"timestamp": time.Now().Format(time.RFC3339),
-		"note": "Send SIGUSR1 to reload config: kill -USR1 <pid>",
+		"note": "Send SIGUSR1 to force an immediate sync: kill -USR1 <pid>",
 	}
 
-	// Add sample mappings (first 5)
+	// Add sample mappings (first 5, in match order)
 	ps.mapMutex.RLock()
 	samples := make([]map[string]string, 0, 5)
-	count := 0
-	for key, value := range ps.shortLinkMap {
-		if count >= 5 {
+	for i, m := range ps.matchers {
+		if i >= 5 {
 			break
 		}
 		samples = append(samples, map[string]string{
-			"short": key,
-			"target": value,
+			"type":             m.Kind.String(),
+			"pattern":          m.Pattern,
+			"target":           m.LongURL,
+			"allow_uri_concat": fmt.Sprintf("%t", m.AllowURIConcat),
 		})
-		count++
 	}
 	ps.mapMutex.RUnlock()
 	response["sample_mappings"] = samples
@@ -214,6 +310,12 @@ func (ps *ProxyServer) handlePac(w http.ResponseWriter, r *http.Request) {
 
 // handleRequest processes HTTP proxy requests
 func (ps *ProxyServer) handleRequest(w http.ResponseWriter, r *http.Request) {
+	// CONNECT tunneling (HTTPS and other TLS traffic)
+	if r.Method == http.MethodConnect {
+		ps.handleConnect(w, r)
+		return
+	}
+
 	// Health check endpoint
 	if r.URL.Path == "/check" || r.URL.Path == "/health" {
 		ps.handleCheck(w, r)
@@ -226,10 +328,22 @@ func (ps *ProxyServer) handleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Increment request counter (excluding health checks and PAC)
-	ps.countMutex.Lock()
-	ps.requestCount++
-	ps.countMutex.Unlock()
+	// Live event stream
+	if r.URL.Path == "/ws/events" {
+		ps.handleEvents(w, r)
+		return
+	}
+
+	// Prometheus metrics endpoint
+	if r.URL.Path == "/metrics" {
+		ps.handleMetrics(w, r)
+		return
+	}
+
+	requestStart := time.Now()
+	defer func() {
+		ps.metrics.ObserveRequestLatency(time.Since(requestStart))
+	}()
 
 	// Extract hostname from Host header
 	host := r.Host
@@ -242,14 +356,48 @@ func (ps *ProxyServer) handleRequest(w http.ResponseWriter, r *http.Request) {
 	hostParts := strings.Split(host, ":")
 	requestedHost := strings.ToLower(hostParts[0])
 
-	// Look up target URL
-	ps.mapMutex.RLock()
-	targetURL, found := ps.shortLinkMap[requestedHost]
-	ps.mapMutex.RUnlock()
+	// Look up target URL against the ordered matcher rules
+	targetURL, allowURIConcat, found := ps.resolveMatch(requestedHost)
+
+	// Increment request counters (excluding health checks, PAC, metrics,
+	// events). requestsByHost is only labeled for hosts that actually
+	// matched a mapping — the raw Host header is client-controlled, so an
+	// unmatched host doesn't get its own label (see notFoundTotal instead).
+	if found {
+		ps.metrics.IncRequest(requestedHost)
+	} else {
+		ps.metrics.IncRequest("")
+	}
 
 	if !found {
 		log.Printf("[%s] No mapping found for host: %s. Returning 404.", time.Now().Format(time.RFC3339), requestedHost)
 		http.Error(w, fmt.Sprintf("No short link mapping found for \"%s\"", requestedHost), http.StatusNotFound)
+		ps.metrics.IncNotFound()
+		ps.events.publish(Event{
+			Type:      "request",
+			Timestamp: time.Now(),
+			ShortHost: requestedHost,
+			ClientIP:  r.RemoteAddr,
+			Status:    http.StatusNotFound,
+			LatencyMs: time.Since(requestStart).Milliseconds(),
+		})
+		return
+	}
+
+	// When AllowURIConcat is false, the short host must be hit exactly; any
+	// extra path is a 404 rather than being appended to the long URL.
+	if !allowURIConcat && r.URL.Path != "" && r.URL.Path != "/" {
+		log.Printf("[%s] Path %q not allowed for exact-match host %s. Returning 404.", time.Now().Format(time.RFC3339), r.URL.Path, requestedHost)
+		http.Error(w, fmt.Sprintf("No short link mapping found for \"%s%s\"", requestedHost, r.URL.Path), http.StatusNotFound)
+		ps.metrics.IncNotFound()
+		ps.events.publish(Event{
+			Type:      "request",
+			Timestamp: time.Now(),
+			ShortHost: requestedHost,
+			ClientIP:  r.RemoteAddr,
+			Status:    http.StatusNotFound,
+			LatencyMs: time.Since(requestStart).Milliseconds(),
+		})
 		return
 	}
 
@@ -274,6 +422,129 @@ func (ps *ProxyServer) handleRequest(w http.ResponseWriter, r *http.Request) {
 
 	// Send 302 redirect
 	http.Redirect(w, r, finalURL, http.StatusFound)
+	ps.metrics.IncRedirect()
+
+	ps.events.publish(Event{
+		Type:      "request",
+		Timestamp: time.Now(),
+		ShortHost: requestedHost,
+		Target:    targetURL,
+		FinalURL:  finalURL,
+		ClientIP:  r.RemoteAddr,
+		Status:    http.StatusFound,
+		LatencyMs: time.Since(requestStart).Milliseconds(),
+	})
+}
+
+// handleConnect dials the real upstream for a CONNECT request and shuttles
+// bytes between the client and that upstream once the tunnel is established.
+func (ps *ProxyServer) handleConnect(w http.ResponseWriter, r *http.Request) {
+	connectStart := time.Now()
+	hostParts := strings.Split(r.Host, ":")
+	requestedHost := strings.ToLower(hostParts[0])
+
+	dialAddr, err := ps.resolveDialAddr(requestedHost)
+	if err != nil {
+		log.Printf("[%s] CONNECT rejected for %s: %v", time.Now().Format(time.RFC3339), r.Host, err)
+		if errors.Is(err, errNoMapping) {
+			http.Error(w, fmt.Sprintf("No short link mapping found for \"%s\"", requestedHost), http.StatusNotFound)
+		} else {
+			http.Error(w, "Internal configuration error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", dialAddr, 10*time.Second)
+	if err != nil {
+		log.Printf("[%s] CONNECT failed to dial %s: %v", time.Now().Format(time.RFC3339), dialAddr, err)
+		http.Error(w, "Failed to reach upstream", http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "Hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	clientConn, _, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		log.Printf("[%s] CONNECT hijack failed: %v", time.Now().Format(time.RFC3339), err)
+		return
+	}
+
+	if _, err := clientConn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		clientConn.Close()
+		upstream.Close()
+		return
+	}
+
+	log.Printf("[%s] CONNECT tunnel established %s -> %s", time.Now().Format(time.RFC3339), r.Host, dialAddr)
+	ps.metrics.IncConnect()
+	ps.events.publish(Event{
+		Type:      "connect",
+		Timestamp: time.Now(),
+		ShortHost: requestedHost,
+		Target:    dialAddr,
+		ClientIP:  r.RemoteAddr,
+		Status:    http.StatusOK,
+		LatencyMs: time.Since(connectStart).Milliseconds(),
+	})
+	tunnel(clientConn, upstream)
+}
+
+// errNoMapping is returned by resolveDialAddr when requestedHost doesn't
+// match any short-link rule. The proxy only tunnels to mapped short-links
+// (per the PAC file's own design: only non-dotted short hostnames go through
+// this proxy, everything else is DIRECT) — it must not relay to whatever
+// destination the client asks for.
+var errNoMapping = errors.New("no short link mapping found")
+
+// resolveDialAddr resolves the host:port to actually dial for a tunneled
+// connection, translating short-link hosts through the matcher rules. It
+// returns errNoMapping if requestedHost isn't a known short-link.
+func (ps *ProxyServer) resolveDialAddr(requestedHost string) (string, error) {
+	targetURL, found := ps.resolveTargetURL(requestedHost)
+	if !found {
+		return "", errNoMapping
+	}
+	return targetAddrFromURL(targetURL)
+}
+
+// targetAddrFromURL extracts the host:port to dial from a mapped target URL,
+// defaulting the port based on scheme when the URL doesn't specify one.
+func targetAddrFromURL(targetURL string) (string, error) {
+	parsed, err := url.Parse(targetURL)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Port() != "" {
+		return parsed.Host, nil
+	}
+	port := "80"
+	if parsed.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(parsed.Hostname(), port), nil
+}
+
+// tunnel shuttles bytes bidirectionally between client and upstream until
+// either side closes, then closes both.
+func tunnel(client, upstream net.Conn) {
+	defer client.Close()
+	defer upstream.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, upstream)
+		done <- struct{}{}
+	}()
+	<-done
 }
 
 // --- Mixed Protocol (HTTP + SOCKS5) Support ---
@@ -341,9 +612,9 @@ func (c *PeekConn) Peek(n int) ([]byte, error) {
 }
 
 func (ps *ProxyServer) Start() error {
-	// Load initial mappings from config file
-	if err := ps.loadMappingsFromFile(); err != nil {
-		log.Fatalf("Failed to load initial config: %v", err)
+	// Load initial mappings from the configured mapping source
+	if err := ps.syncMappings(context.Background()); err != nil {
+		log.Fatalf("Failed to load initial mappings: %v", err)
 	}
 
 	// Create the actual TCP listener
@@ -360,19 +631,51 @@ func (ps *ProxyServer) Start() error {
 		Handler: http.HandlerFunc(ps.handleRequest),
 	}
 
+	// Create the HTTPS server, if TLS_CERT_FILE/TLS_KEY_FILE are configured
+	tlsConfig, err := ps.buildTLSConfig()
+	if err != nil {
+		return fmt.Errorf("failed to build TLS config: %w", err)
+	}
+	var httpsServer *http.Server
+	if tlsConfig != nil {
+		ps.httpsListener = NewChannelListener(listener.Addr())
+		httpsServer = &http.Server{
+			Handler:   http.HandlerFunc(ps.handleRequest),
+			TLSConfig: tlsConfig,
+		}
+	}
+
 	// Setup signal handlers
 	shutdownChan := make(chan os.Signal, 1)
 	reloadChan := make(chan os.Signal, 1)
 	signal.Notify(shutdownChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 	signal.Notify(reloadChan, syscall.SIGUSR1)
 
-	// Handle reload signal
+	// Handle reload signal (forces an immediate sync)
 	go func() {
 		for range reloadChan {
 			ps.reloadMappings()
 		}
 	}()
 
+	// Periodically poll the mapping source on SYNC_INTERVAL
+	syncCtx, syncCancel := context.WithCancel(context.Background())
+	defer syncCancel()
+	go func() {
+		ticker := time.NewTicker(ps.config.SyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := ps.syncMappings(syncCtx); err != nil {
+					log.Printf("[%s] Periodic mapping sync failed: %v", time.Now().Format(time.RFC3339), err)
+				}
+			case <-syncCtx.Done():
+				return
+			}
+		}
+	}()
+
 	// Start connection dispatcher
 	go func() {
 		for {
@@ -394,12 +697,22 @@ func (ps *ProxyServer) Start() error {
 	go func() {
 		log.Printf("[%s] Trip Short Link Proxy (SOCKS5+HTTP) listening on port %s", time.Now().Format(time.RFC3339), ps.config.Port)
 		log.Printf("[%s] Config file: %s", time.Now().Format(time.RFC3339), ps.config.ConfigFile)
-		
+
 		if err := server.Serve(httpListener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
 	}()
 
+	// Start HTTPS server using our virtual listener, if configured
+	if httpsServer != nil {
+		go func() {
+			log.Printf("[%s] TLS-terminating HTTPS listener active on port %s (SNI routed through the matcher rules)", time.Now().Format(time.RFC3339), ps.config.Port)
+			if err := httpsServer.ServeTLS(ps.httpsListener, "", ""); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("HTTPS server failed to start: %v", err)
+			}
+		}()
+	}
+
 	// Wait for shutdown
 	sig := <-shutdownChan
 	log.Printf("[%s] %s signal received: shutting down gracefully...", time.Now().Format(time.RFC3339), sig)
@@ -414,6 +727,12 @@ func (ps *ProxyServer) Start() error {
 		log.Printf("Error during server shutdown: %v", err)
 		return err
 	}
+	if httpsServer != nil {
+		if err := httpsServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error during HTTPS server shutdown: %v", err)
+			return err
+		}
+	}
 
 	log.Printf("[%s] HTTP server closed successfully", time.Now().Format(time.RFC3339))
 	return nil
@@ -436,16 +755,34 @@ func (ps *ProxyServer) handleConnection(rawConn net.Conn, httpListener *ChannelL
 
 	// Check for SOCKS5 (0x05)
 	if head[0] == 0x05 {
-		if err := handleSocks5Handshake(conn); err != nil {
+		// handleSocks5Handshake owns the connection end-to-end: it dials the
+		// requested upstream itself and relays the tunnel, so it closes conn
+		// when the tunnel ends rather than handing it off to the HTTP server.
+		if err := ps.handleSocks5Handshake(conn); err != nil {
 			log.Printf("SOCKS5 handshake failed: %v", err)
 			conn.Close()
+		}
+		return
+	}
+
+	// Check for a TLS ClientHello (0x16); route to the HTTPS listener so
+	// http.Server's ServeTLS can perform the handshake (which itself
+	// validates SNI against the matcher rules via TLSConfig.GetConfigForClient).
+	if head[0] == 0x16 {
+		if ps.httpsListener == nil {
+			log.Printf("[%s] TLS ClientHello received but no TLS_CERT_FILE/TLS_KEY_FILE configured; closing connection", time.Now().Format(time.RFC3339))
+			conn.Close()
 			return
 		}
-		// After successful handshake, the client will send the actual HTTP request.
-		// We pass the connection (which is now positioned at the start of HTTP request) to the HTTP server.
-	} 
-	
-	// Pass to HTTP server (either it was HTTP all along, or we unwrapped SOCKS5)
+		select {
+		case ps.httpsListener.conns <- conn:
+		case <-ps.httpsListener.closed:
+			conn.Close()
+		}
+		return
+	}
+
+	// Pass to HTTP server (plain HTTP request)
 	// We need to be careful not to block if the server is shutting down
 	select {
 	case httpListener.conns <- conn:
@@ -454,8 +791,13 @@ func (ps *ProxyServer) handleConnection(rawConn net.Conn, httpListener *ChannelL
 	}
 }
 
-// handleSocks5Handshake performs a minimal SOCKS5 server handshake
-func handleSocks5Handshake(conn io.ReadWriter) error {
+// handleSocks5Handshake performs a SOCKS5 server handshake, resolves the
+// requested destination (translating short-link hosts through
+// the matcher rules), dials the upstream, and relays the tunnel. Once the
+// tunnel is up it owns conn and closes it when the tunnel ends; when it
+// returns an error, conn is left open for the caller to close.
+func (ps *ProxyServer) handleSocks5Handshake(conn net.Conn) error {
+	handshakeStart := time.Now()
 	// 1. Client Greeting
 	// Version (1) + NMethods (1) + Methods (N)
 	buf := make([]byte, 258)
@@ -484,18 +826,20 @@ func handleSocks5Handshake(conn io.ReadWriter) error {
 	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
 		return fmt.Errorf("read request header: %w", err)
 	}
-	
+
 	cmd := buf[1]
 	if cmd != 1 { // CONNECT
 		return fmt.Errorf("unsupported command: %d", cmd)
 	}
-	
-atyp := buf[3]
+
+	atyp := buf[3]
+	var destHost string
 	switch atyp {
 	case 1: // IPv4
 		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
 			return err
 		}
+		destHost = net.IP(buf[:4]).String()
 	case 3: // Domain
 		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
 			return err
@@ -504,35 +848,84 @@ atyp := buf[3]
 		if _, err := io.ReadFull(conn, buf[:addrLen]); err != nil {
 			return err
 		}
+		destHost = string(buf[:addrLen])
 	case 4: // IPv6
 		if _, err := io.ReadFull(conn, buf[:16]); err != nil {
 			return err
 		}
+		destHost = net.IP(buf[:16]).String()
 	default:
 		return fmt.Errorf("unsupported address type: %d", atyp)
 	}
-	
-	// Read Port
+
+	// Read Port (part of the wire protocol; unused once we reject anything
+	// that isn't a mapped short-link, since we always dial the mapped target)
 	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
 		return err
 	}
 
+	dialAddr, err := ps.resolveDialAddr(strings.ToLower(destHost))
+	if err != nil {
+		if errors.Is(err, errNoMapping) {
+			ps.writeSocks5Reply(conn, 0x02, nil) // connection not allowed by ruleset
+		} else {
+			ps.writeSocks5Reply(conn, 0x01, nil) // general failure
+		}
+		return fmt.Errorf("resolve destination %s: %w", destHost, err)
+	}
+
+	upstream, err := net.DialTimeout("tcp", dialAddr, 10*time.Second)
+	if err != nil {
+		ps.writeSocks5Reply(conn, 0x05, nil) // connection refused
+		return fmt.Errorf("dial upstream %s: %w", dialAddr, err)
+	}
+
 	// 4. Server Reply
 	// Ver(1) + Rep(1) + Rsv(1) + Atyp(1) + BndAddr(?) + BndPort(2)
-	// Rep: 0x00 (Succeeded)
-	// We just return 0.0.0.0:0 as bound address
-	response := []byte{
-		0x05, 0x00, 0x00, 0x01,
-		0x00, 0x00, 0x00, 0x00,
-		0x00, 0x00,
-	}
-	if _, err := conn.Write(response); err != nil {
+	// Rep: 0x00 (Succeeded), reflecting the address we actually bound to.
+	if err := ps.writeSocks5Reply(conn, 0x00, upstream.LocalAddr()); err != nil {
+		upstream.Close()
 		return fmt.Errorf("write reply: %w", err)
 	}
 
+	log.Printf("[%s] SOCKS5 tunnel established %s -> %s", time.Now().Format(time.RFC3339), destHost, dialAddr)
+	ps.metrics.IncSocks()
+	ps.events.publish(Event{
+		Type:      "socks5",
+		Timestamp: time.Now(),
+		ShortHost: strings.ToLower(destHost),
+		Target:    dialAddr,
+		ClientIP:  conn.RemoteAddr().String(),
+		Status:    http.StatusOK,
+		LatencyMs: time.Since(handshakeStart).Milliseconds(),
+	})
+	tunnel(conn, upstream)
 	return nil
 }
 
+// writeSocks5Reply sends a SOCKS5 reply with the given reply code, encoding
+// boundAddr (if it's a *net.TCPAddr) as an IPv4 bound address; otherwise it
+// falls back to 0.0.0.0:0. An IPv6 bound address is sent as 0.0.0.0 with its
+// port.
+func (ps *ProxyServer) writeSocks5Reply(conn net.Conn, rep byte, boundAddr net.Addr) error {
+	ip := net.IPv4zero
+	port := 0
+	if tcpAddr, ok := boundAddr.(*net.TCPAddr); ok {
+		if v4 := tcpAddr.IP.To4(); v4 != nil {
+			ip = v4
+		}
+		port = tcpAddr.Port
+	}
+
+	response := make([]byte, 0, 10)
+	response = append(response, 0x05, rep, 0x00, 0x01)
+	response = append(response, ip.To4()...)
+	response = append(response, byte(port>>8), byte(port))
+
+	_, err := conn.Write(response)
+	return err
+}
+
 func main() {
 	startTime = time.Now()
 	config := loadConfig()
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestResolveDialAddrRejectsUnmappedHost(t *testing.T) {
+	matcher, err := newMatcher("known.example", "https://long.example:8443", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := &ProxyServer{matchers: []*Matcher{matcher}}
+
+	if _, err := ps.resolveDialAddr("unmapped.example"); !errors.Is(err, errNoMapping) {
+		t.Fatalf("resolveDialAddr(unmapped.example) error = %v, want errNoMapping", err)
+	}
+
+	dialAddr, err := ps.resolveDialAddr("known.example")
+	if err != nil {
+		t.Fatalf("resolveDialAddr(known.example) unexpected error: %v", err)
+	}
+	if want := "long.example:8443"; dialAddr != want {
+		t.Errorf("resolveDialAddr(known.example) = %q, want %q", dialAddr, want)
+	}
+}
+
+// TestHandleSocks5HandshakeRejectsUnmappedHost is a regression test for the
+// open-proxy defect where CONNECT/SOCKS5 fell back to dialing whatever
+// destination the client asked for when it wasn't a known short-link. It
+// drives a real SOCKS5 handshake over a net.Pipe and asserts the server
+// replies with 0x02 (connection not allowed by ruleset) instead of tunneling.
+func TestHandleSocks5HandshakeRejectsUnmappedHost(t *testing.T) {
+	matcher, err := newMatcher("known.example", "https://long.example", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := &ProxyServer{matchers: []*Matcher{matcher}, events: newEventBus(), metrics: newMetrics()}
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	handshakeDone := make(chan error, 1)
+	go func() {
+		handshakeDone <- ps.handleSocks5Handshake(server)
+	}()
+
+	// 1. Client greeting: VER=5, NMETHODS=1, METHODS=[no-auth].
+	if _, err := client.Write([]byte{0x05, 0x01, 0x00}); err != nil {
+		t.Fatalf("write greeting: %v", err)
+	}
+	choice := make([]byte, 2)
+	if _, err := io.ReadFull(client, choice); err != nil {
+		t.Fatalf("read server choice: %v", err)
+	}
+	if choice[0] != 0x05 || choice[1] != 0x00 {
+		t.Fatalf("server choice = %v, want [5 0]", choice)
+	}
+
+	// 2. Client request: CONNECT to a domain that isn't a mapped short-link.
+	host := "attacker-controlled.example"
+	req := []byte{0x05, 0x01, 0x00, 0x03, byte(len(host))}
+	req = append(req, host...)
+	req = append(req, 0x00, 0x50) // port 80
+	if _, err := client.Write(req); err != nil {
+		t.Fatalf("write request: %v", err)
+	}
+
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(client, reply); err != nil {
+		t.Fatalf("read server reply: %v", err)
+	}
+	if reply[0] != 0x05 || reply[1] != 0x02 {
+		t.Fatalf("server reply = %v, want VER=5 REP=0x02 (connection not allowed by ruleset)", reply)
+	}
+
+	if err := <-handshakeDone; !errors.Is(err, errNoMapping) {
+		t.Fatalf("handleSocks5Handshake error = %v, want errNoMapping", err)
+	}
+}
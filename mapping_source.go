@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// MappingSource fetches the current set of short-link records from wherever
+// they're authored (a local file, an upstream HTTP API, ...). Fetch should
+// honor prevETag/prevLastModified as conditional-request hints and report
+// notModified when the source confirms nothing changed, so callers can skip
+// re-parsing and re-swapping the map on an unchanged poll.
+type MappingSource interface {
+	Fetch(ctx context.Context, prevETag, prevLastModified string) (resp *APIResponse, etag string, lastModified string, notModified bool, err error)
+}
+
+// newMappingSource builds a MappingSource from a source URL of the form
+// file://path, http(s)://host/path, or a bare path (treated as file://).
+func newMappingSource(rawSource string) (MappingSource, error) {
+	if rawSource == "" {
+		return nil, fmt.Errorf("mapping source is empty")
+	}
+
+	parsed, err := url.Parse(rawSource)
+	if err != nil || parsed.Scheme == "" {
+		// Bare path, e.g. "config.json"
+		return &fileMappingSource{path: rawSource}, nil
+	}
+
+	switch parsed.Scheme {
+	case "file":
+		// file://config.json puts a relative path in the host part.
+		path := parsed.Host + parsed.Path
+		if path == "" {
+			path = parsed.Opaque
+		}
+		return &fileMappingSource{path: path}, nil
+	case "http", "https":
+		return &httpMappingSource{
+			url:    rawSource,
+			client: &http.Client{Timeout: 15 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported mapping source scheme %q", parsed.Scheme)
+	}
+}
+
+// fileMappingSource reads mappings from a local JSON file, using the file's
+// mtime as a cheap stand-in for ETag/Last-Modified.
+type fileMappingSource struct {
+	path string
+}
+
+func (f *fileMappingSource) Fetch(ctx context.Context, prevETag, prevLastModified string) (*APIResponse, string, string, bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	modTime := info.ModTime().UTC().Format(http.TimeFormat)
+	if prevLastModified != "" && modTime == prevLastModified {
+		return nil, prevETag, prevLastModified, true, nil
+	}
+
+	body, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+
+	var apiResp APIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, "", "", false, fmt.Errorf("parse %s: %w", f.path, err)
+	}
+
+	return &apiResp, modTime, modTime, false, nil
+}
+
+// httpMappingSource polls an upstream API matching APIResponse, using
+// conditional GETs so unchanged mappings don't cost a full re-parse.
+type httpMappingSource struct {
+	url    string
+	client *http.Client
+}
+
+func (h *httpMappingSource) Fetch(ctx context.Context, prevETag, prevLastModified string) (*APIResponse, string, string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	if prevETag != "" {
+		req.Header.Set("If-None-Match", prevETag)
+	}
+	if prevLastModified != "" {
+		req.Header.Set("If-Modified-Since", prevLastModified)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, prevETag, prevLastModified, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", false, fmt.Errorf("mapping source %s returned status %d", h.url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", false, err
+	}
+
+	var apiResp APIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, "", "", false, fmt.Errorf("parse response from %s: %w", h.url, err)
+	}
+
+	return &apiResp, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), false, nil
+}
+
+// defaultMappingSourceURL derives a MappingSource URL from the legacy
+// CONFIG_FILE setting when MAPPING_SOURCE isn't set, so existing
+// deployments keep working unchanged.
+func defaultMappingSourceURL(configFile string) string {
+	if strings.Contains(configFile, "://") {
+		return configFile
+	}
+	return "file://" + configFile
+}
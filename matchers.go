@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MatcherKind distinguishes how a Matcher's Pattern is tested against the
+// incoming request host.
+type MatcherKind int
+
+const (
+	MatcherExact MatcherKind = iota
+	MatcherPrefix
+	MatcherRegex
+)
+
+func (k MatcherKind) String() string {
+	switch k {
+	case MatcherPrefix:
+		return "prefix"
+	case MatcherRegex:
+		return "regex"
+	default:
+		return "exact"
+	}
+}
+
+// Matcher is one short-link rule. Rules are evaluated in the order they
+// appear in the mapping source, so an earlier regex/prefix rule can shadow
+// later ones, the same way a router matches routes top to bottom.
+type Matcher struct {
+	Kind           MatcherKind
+	Pattern        string
+	LongURL        string
+	AllowURIConcat bool
+
+	compiledRegex *regexp.Regexp
+}
+
+// newMatcher builds a Matcher from a record's ShortURL, recognizing the
+// "prefix:" and "regex:" prefixes; anything else is an exact-match short
+// host, matching the original one-row-per-host behavior.
+func newMatcher(shortURL, longURL string, allowURIConcat bool) (*Matcher, error) {
+	switch {
+	case strings.HasPrefix(shortURL, "prefix:"):
+		pattern := strings.ToLower(strings.TrimPrefix(shortURL, "prefix:"))
+		if pattern == "" {
+			return nil, fmt.Errorf("empty prefix pattern")
+		}
+		return &Matcher{Kind: MatcherPrefix, Pattern: pattern, LongURL: longURL, AllowURIConcat: allowURIConcat}, nil
+	case strings.HasPrefix(shortURL, "regex:"):
+		pattern := strings.TrimPrefix(shortURL, "regex:")
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("compile regex pattern %q: %w", pattern, err)
+		}
+		return &Matcher{Kind: MatcherRegex, Pattern: pattern, LongURL: longURL, AllowURIConcat: allowURIConcat, compiledRegex: re}, nil
+	default:
+		return &Matcher{Kind: MatcherExact, Pattern: strings.ToLower(shortURL), LongURL: longURL, AllowURIConcat: allowURIConcat}, nil
+	}
+}
+
+// match tests host against the rule, returning the long URL to use (with
+// any regex capture groups expanded into it, e.g. "$1") and whether it
+// matched at all.
+func (m *Matcher) match(host string) (longURL string, matched bool) {
+	switch m.Kind {
+	case MatcherPrefix:
+		if strings.HasPrefix(host, m.Pattern) {
+			return m.LongURL, true
+		}
+	case MatcherRegex:
+		loc := m.compiledRegex.FindStringSubmatchIndex(host)
+		if loc == nil {
+			return "", false
+		}
+		expanded := m.compiledRegex.ExpandString(nil, m.LongURL, host, loc)
+		return string(expanded), true
+	default: // MatcherExact
+		if host == m.Pattern {
+			return m.LongURL, true
+		}
+	}
+	return "", false
+}
+
+// resolveMatch walks the compiled matchers in order and returns the first
+// one whose rule matches host.
+func (ps *ProxyServer) resolveMatch(host string) (longURL string, allowURIConcat bool, found bool) {
+	ps.mapMutex.RLock()
+	defer ps.mapMutex.RUnlock()
+	for _, m := range ps.matchers {
+		if expanded, matched := m.match(host); matched {
+			return expanded, m.AllowURIConcat, true
+		}
+	}
+	return "", false, false
+}
+
+// resolveTargetURL is resolveMatch without the AllowURIConcat flag, for
+// callers (CONNECT, SOCKS5, TLS SNI) that only need to know the upstream
+// target and don't apply path-concatenation semantics.
+func (ps *ProxyServer) resolveTargetURL(host string) (longURL string, found bool) {
+	longURL, _, found = ps.resolveMatch(host)
+	return longURL, found
+}
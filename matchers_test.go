@@ -0,0 +1,113 @@
+package main
+
+import "testing"
+
+func TestNewMatcherKind(t *testing.T) {
+	tests := []struct {
+		name      string
+		shortURL  string
+		wantKind  MatcherKind
+		wantErr   bool
+		wantRegex bool
+	}{
+		{name: "exact", shortURL: "go.example", wantKind: MatcherExact},
+		{name: "prefix", shortURL: "prefix:docs/", wantKind: MatcherPrefix},
+		{name: "empty prefix", shortURL: "prefix:", wantErr: true},
+		{name: "regex", shortURL: "regex:^go-(\\d+)\\.example$", wantKind: MatcherRegex, wantRegex: true},
+		{name: "invalid regex", shortURL: "regex:(", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := newMatcher(tt.shortURL, "https://example.com", false)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("newMatcher(%q) = nil error, want error", tt.shortURL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newMatcher(%q) unexpected error: %v", tt.shortURL, err)
+			}
+			if m.Kind != tt.wantKind {
+				t.Errorf("Kind = %v, want %v", m.Kind, tt.wantKind)
+			}
+			if tt.wantRegex && m.compiledRegex == nil {
+				t.Errorf("compiledRegex = nil, want compiled pattern")
+			}
+		})
+	}
+}
+
+func TestMatcherMatchExact(t *testing.T) {
+	m, err := newMatcher("go.example", "https://long.example/path", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if longURL, matched := m.match("go.example"); !matched || longURL != "https://long.example/path" {
+		t.Errorf("match(go.example) = %q, %v, want https://long.example/path, true", longURL, matched)
+	}
+	if _, matched := m.match("other.example"); matched {
+		t.Errorf("match(other.example) = true, want false")
+	}
+}
+
+func TestMatcherMatchPrefix(t *testing.T) {
+	m, err := newMatcher("prefix:docs/", "https://long.example", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if longURL, matched := m.match("docs/getting-started"); !matched || longURL != "https://long.example" {
+		t.Errorf("match(docs/getting-started) = %q, %v, want https://long.example, true", longURL, matched)
+	}
+	if _, matched := m.match("other/getting-started"); matched {
+		t.Errorf("match(other/getting-started) = true, want false")
+	}
+}
+
+func TestMatcherMatchRegexExpandsCaptureGroups(t *testing.T) {
+	m, err := newMatcher("regex:^go-(\\d+)\\.example$", "https://long.example/v$1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	longURL, matched := m.match("go-42.example")
+	if !matched {
+		t.Fatalf("match(go-42.example) = false, want true")
+	}
+	if want := "https://long.example/v42"; longURL != want {
+		t.Errorf("match(go-42.example) longURL = %q, want %q", longURL, want)
+	}
+	if _, matched := m.match("go-abc.example"); matched {
+		t.Errorf("match(go-abc.example) = true, want false")
+	}
+}
+
+func TestResolveMatchHonorsAllowURIConcatAndRuleOrder(t *testing.T) {
+	exact, err := newMatcher("go.example", "https://long.example", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, err := newMatcher("prefix:go.example", "https://other.example", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps := &ProxyServer{matchers: []*Matcher{exact, prefix}}
+
+	longURL, allowURIConcat, found := ps.resolveMatch("go.example")
+	if !found || longURL != "https://long.example" || allowURIConcat {
+		t.Errorf("resolveMatch(go.example) = %q, %v, %v; want https://long.example, false, true (earlier exact rule should win)", longURL, allowURIConcat, found)
+	}
+
+	longURL, allowURIConcat, found = ps.resolveMatch("go.example/extra")
+	if !found || longURL != "https://other.example" || !allowURIConcat {
+		t.Errorf("resolveMatch(go.example/extra) = %q, %v, %v; want https://other.example, true, true", longURL, allowURIConcat, found)
+	}
+
+	if _, found := ps.resolveTargetURL("unmapped.example"); found {
+		t.Errorf("resolveTargetURL(unmapped.example) = found, want not found")
+	}
+}
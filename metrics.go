@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// histogram is a minimal Prometheus-style histogram: fixed, pre-declared
+// bucket upper bounds with cumulative per-bucket counts, a sum, and a count.
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	return &histogram{buckets: sorted, counts: make([]uint64, len(sorted))}
+}
+
+func (h *histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sum += v
+	h.count++
+	for i, upperBound := range h.buckets {
+		if v <= upperBound {
+			h.counts[i]++
+		}
+	}
+}
+
+func (h *histogram) snapshot() (buckets []float64, counts []uint64, sum float64, count uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]float64(nil), h.buckets...), append([]uint64(nil), h.counts...), h.sum, h.count
+}
+
+// Metrics is the proxy's Prometheus metrics registry: a handful of counters
+// plus a latency histogram for handleRequest. It replaces the old ad-hoc
+// requestCount/countMutex pair with something /metrics can expose directly.
+type Metrics struct {
+	mu                 sync.Mutex
+	requestsTotal      uint64
+	requestsByHost     map[string]uint64
+	notFoundTotal      uint64
+	redirectTotal      uint64
+	connectTotal       uint64
+	socksTotal         uint64
+	reloadSuccessTotal uint64
+	reloadFailureTotal uint64
+
+	requestLatency *histogram
+}
+
+func newMetrics() *Metrics {
+	return &Metrics{
+		requestsByHost: make(map[string]uint64),
+		requestLatency: newHistogram([]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}),
+	}
+}
+
+func (m *Metrics) RequestsTotal() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requestsTotal
+}
+
+// IncRequest counts a proxied request against requestsTotal. matchedHost
+// should be the short host the request resolved to, or "" if it didn't
+// match any mapping — an unresolved request still counts toward the total,
+// but not toward requestsByHost, since that label is keyed by an
+// attacker-controlled Host header and must not grow without bound.
+func (m *Metrics) IncRequest(matchedHost string) {
+	m.mu.Lock()
+	m.requestsTotal++
+	if matchedHost != "" {
+		m.requestsByHost[matchedHost]++
+	}
+	m.mu.Unlock()
+}
+
+func (m *Metrics) IncNotFound() {
+	m.mu.Lock()
+	m.notFoundTotal++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) IncRedirect() {
+	m.mu.Lock()
+	m.redirectTotal++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) IncConnect() {
+	m.mu.Lock()
+	m.connectTotal++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) IncSocks() {
+	m.mu.Lock()
+	m.socksTotal++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) IncReload(success bool) {
+	m.mu.Lock()
+	if success {
+		m.reloadSuccessTotal++
+	} else {
+		m.reloadFailureTotal++
+	}
+	m.mu.Unlock()
+}
+
+func (m *Metrics) ObserveRequestLatency(d time.Duration) {
+	m.requestLatency.Observe(d.Seconds())
+}
+
+// WriteTo renders the registry plus the live gauges (map size, last-sync age)
+// as Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer, mapSize int, lastSyncAge time.Duration) {
+	m.mu.Lock()
+	requestsTotal := m.requestsTotal
+	notFoundTotal := m.notFoundTotal
+	redirectTotal := m.redirectTotal
+	connectTotal := m.connectTotal
+	socksTotal := m.socksTotal
+	reloadSuccessTotal := m.reloadSuccessTotal
+	reloadFailureTotal := m.reloadFailureTotal
+	byHost := make(map[string]uint64, len(m.requestsByHost))
+	for host, count := range m.requestsByHost {
+		byHost[host] = count
+	}
+	m.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP trip_proxy_requests_total Total proxied requests handled.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_requests_total counter\n")
+	fmt.Fprintf(w, "trip_proxy_requests_total %d\n", requestsTotal)
+
+	fmt.Fprintf(w, "# HELP trip_proxy_requests_by_host_total Proxied requests per short host.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_requests_by_host_total counter\n")
+	hosts := make([]string, 0, len(byHost))
+	for host := range byHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		fmt.Fprintf(w, "trip_proxy_requests_by_host_total{short=%q} %d\n", host, byHost[host])
+	}
+
+	fmt.Fprintf(w, "# HELP trip_proxy_not_found_total Requests answered 404: unknown short host, or extra path on an exact-match host.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_not_found_total counter\n")
+	fmt.Fprintf(w, "trip_proxy_not_found_total %d\n", notFoundTotal)
+
+	fmt.Fprintf(w, "# HELP trip_proxy_redirect_total HTTP 302 redirects served.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_redirect_total counter\n")
+	fmt.Fprintf(w, "trip_proxy_redirect_total %d\n", redirectTotal)
+
+	fmt.Fprintf(w, "# HELP trip_proxy_connect_total CONNECT tunnels established.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_connect_total counter\n")
+	fmt.Fprintf(w, "trip_proxy_connect_total %d\n", connectTotal)
+
+	fmt.Fprintf(w, "# HELP trip_proxy_socks_total SOCKS5 tunnels established.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_socks_total counter\n")
+	fmt.Fprintf(w, "trip_proxy_socks_total %d\n", socksTotal)
+
+	fmt.Fprintf(w, "# HELP trip_proxy_mapping_reload_total Mapping source sync attempts by outcome.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_mapping_reload_total counter\n")
+	fmt.Fprintf(w, "trip_proxy_mapping_reload_total{outcome=\"success\"} %d\n", reloadSuccessTotal)
+	fmt.Fprintf(w, "trip_proxy_mapping_reload_total{outcome=\"failure\"} %d\n", reloadFailureTotal)
+
+	fmt.Fprintf(w, "# HELP trip_proxy_mappings_current Current number of loaded short-link mappings.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_mappings_current gauge\n")
+	fmt.Fprintf(w, "trip_proxy_mappings_current %d\n", mapSize)
+
+	fmt.Fprintf(w, "# HELP trip_proxy_last_sync_age_seconds Seconds since the last mapping sync attempt.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_last_sync_age_seconds gauge\n")
+	fmt.Fprintf(w, "trip_proxy_last_sync_age_seconds %f\n", lastSyncAge.Seconds())
+
+	buckets, counts, sum, count := m.requestLatency.snapshot()
+	fmt.Fprintf(w, "# HELP trip_proxy_request_latency_seconds Latency of handleRequest.\n")
+	fmt.Fprintf(w, "# TYPE trip_proxy_request_latency_seconds histogram\n")
+	for i, upperBound := range buckets {
+		fmt.Fprintf(w, "trip_proxy_request_latency_seconds_bucket{le=%q} %d\n", formatFloat(upperBound), counts[i])
+	}
+	fmt.Fprintf(w, "trip_proxy_request_latency_seconds_bucket{le=\"+Inf\"} %d\n", count)
+	fmt.Fprintf(w, "trip_proxy_request_latency_seconds_sum %f\n", sum)
+	fmt.Fprintf(w, "trip_proxy_request_latency_seconds_count %d\n", count)
+}
+
+func formatFloat(f float64) string {
+	s := fmt.Sprintf("%g", f)
+	if !strings.Contains(s, ".") && !strings.Contains(s, "e") {
+		s += ".0"
+	}
+	return s
+}
+
+// handleMetrics exposes the registry in Prometheus text format.
+func (ps *ProxyServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	ps.mapMutex.RLock()
+	mapSize := len(ps.matchers)
+	lastSyncAge := time.Since(ps.lastSyncTime)
+	ps.mapMutex.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	ps.metrics.WriteTo(w, mapSize, lastSyncAge)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+)
+
+// buildTLSConfig loads the configured certificate/key pair and returns a
+// *tls.Config for the HTTPS listener, or nil if TLS isn't configured
+// (TLS_CERT_FILE/TLS_KEY_FILE unset). GetConfigForClient validates the SNI
+// host against the matcher rules before the handshake completes, so an
+// unknown host fails the TLS handshake and never reaches handleRequest.
+func (ps *ProxyServer) buildTLSConfig() (*tls.Config, error) {
+	if ps.config.TLSCertFile == "" && ps.config.TLSKeyFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(ps.config.TLSCertFile, ps.config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS cert/key: %w", err)
+	}
+	baseConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	return &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			host := strings.ToLower(hello.ServerName)
+			if _, found := ps.resolveTargetURL(host); !found {
+				return nil, fmt.Errorf("no short link mapping for TLS SNI host %q", host)
+			}
+			return baseConfig, nil
+		},
+	}, nil
+}